@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"github.com/deluan/navidrome/conf"
+	"github.com/deluan/navidrome/log"
+	"github.com/deluan/navidrome/scanner/watcher"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+}
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Watch music folder for changes",
+	Long:  "Watch music folder for changes and incrementally update the index, instead of doing periodic full rescans",
+	Run: func(cmd *cobra.Command, args []string) {
+		if !conf.Server.Scanner.WatchChanges {
+			log.Error("Scanner.WatchChanges is disabled in configuration; enable it, or run with --full rescans instead")
+			return
+		}
+		runWatcher()
+	},
+}
+
+// StartWatcherIfEnabled launches the incremental watcher as a background goroutine when
+// Scanner.WatchChanges is on. The main `navidrome server` process (outside this package)
+// should call this once at startup, alongside its normal periodic RescanAll, so watching
+// happens automatically instead of requiring the separate `navidrome watch` command.
+func StartWatcherIfEnabled() {
+	if !conf.Server.Scanner.WatchChanges {
+		return
+	}
+	go runWatcher()
+}
+
+func runWatcher() {
+	conf.Server.DevPreCacheAlbumArtwork = false
+
+	scanner := GetScanner()
+	ds := GetDataStore()
+	w := watcher.New(conf.Server.MusicFolder, ds, scanner)
+	if err := w.Run(); err != nil {
+		log.Error("Watcher stopped", err)
+	}
+}
@@ -0,0 +1,39 @@
+package model
+
+// ArtistRole describes how an artist contributed to a media file or album.
+type ArtistRole string
+
+const (
+	RoleMain      ArtistRole = "main"
+	RoleFeatured  ArtistRole = "featured"
+	RoleRemixer   ArtistRole = "remixer"
+	RoleComposer  ArtistRole = "composer"
+	RoleConductor ArtistRole = "conductor"
+)
+
+// MediaFileArtist represents one row of the media_file_artists join table, linking a
+// MediaFile to an Artist with the role they played and their position among artists
+// sharing that role (e.g. multiple featured artists, in credited order).
+type MediaFileArtist struct {
+	MediaFileID string     `json:"mediaFileId"`
+	ArtistID    string     `json:"artistId"`
+	Name        string     `json:"name"`
+	MbzArtistID string     `json:"mbzArtistId"`
+	Role        ArtistRole `json:"role"`
+	Order       int        `json:"order"`
+}
+
+type MediaFileArtists []MediaFileArtist
+
+// AlbumArtist represents one row of the album_artists join table, the album-level
+// equivalent of MediaFileArtist.
+type AlbumArtist struct {
+	AlbumID     string     `json:"albumId"`
+	ArtistID    string     `json:"artistId"`
+	Name        string     `json:"name"`
+	MbzArtistID string     `json:"mbzArtistId"`
+	Role        ArtistRole `json:"role"`
+	Order       int        `json:"order"`
+}
+
+type AlbumArtists []AlbumArtist
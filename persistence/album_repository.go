@@ -0,0 +1,125 @@
+package persistence
+
+import (
+	"context"
+
+	. "github.com/Masterminds/squirrel"
+	"github.com/astaxie/beego/orm"
+	"github.com/deluan/navidrome/model"
+	"github.com/deluan/rest"
+)
+
+type albumRepository struct {
+	sqlRepository
+	sqlRestful
+}
+
+func NewAlbumRepository(ctx context.Context, o orm.Ormer) model.AlbumRepository {
+	ensureStableIDs(ctx, o)
+	r := &albumRepository{}
+	r.ctx = ctx
+	r.ormer = o
+	r.tableName = "album"
+	r.sortMappings = map[string]string{
+		"name": "order_album_name",
+	}
+	r.filterMappings = map[string]filterFunc{
+		"name":    fullTextFilter,
+		"starred": booleanFilter,
+	}
+	return r
+}
+
+func (r *albumRepository) selectAlbum(options ...model.QueryOptions) SelectBuilder {
+	return r.newSelectWithAnnotation("album.id", options...).Columns("*")
+}
+
+func (r *albumRepository) CountAll(options ...model.QueryOptions) (int64, error) {
+	return r.count(r.newSelectWithAnnotation("album.id"), options...)
+}
+
+func (r *albumRepository) Exists(id string) (bool, error) {
+	return r.exists(Select().Where(Eq{"id": id}))
+}
+
+// Put saves a and, derived from a.AlbumArtist, (re)writes its album_artists rows. This
+// matters because artistRepository.refresh and purgeEmpty pivot off album_artists, so a
+// newly-scanned album with no rows there would never get its artist created, and any
+// existing artist with no rows would be purged.
+func (r *albumRepository) Put(a *model.Album) error {
+	_, err := r.put(a.ID, a)
+	if err != nil {
+		return err
+	}
+	return newAlbumArtistRepository(r.ctx, r.ormer).replace(a.ID, albumContributors(a))
+}
+
+func (r *albumRepository) Get(id string) (*model.Album, error) {
+	sel := r.selectAlbum().Where(Eq{"id": id})
+	var res []model.Album
+	if err := r.queryAll(sel, &res); err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, model.ErrNotFound
+	}
+	return &res[0], nil
+}
+
+func (r *albumRepository) GetAll(options ...model.QueryOptions) (model.Albums, error) {
+	sel := r.selectAlbum(options...)
+	var res model.Albums
+	err := r.queryAll(sel, &res)
+	return res, err
+}
+
+// GetArtists returns every artist credited on albumID, across all roles, in credited
+// order, so a featured album artist is browsable from their own artist page.
+func (r *albumRepository) GetArtists(albumID string) (model.AlbumArtists, error) {
+	sel := Select("album_id", "artist_id", "role", "seq as \"order\"").
+		From("album_artists").
+		Where(Eq{"album_id": albumID}).
+		OrderBy("seq")
+	var res model.AlbumArtists
+	err := r.queryAll(sel, &res)
+	return res, err
+}
+
+func (r *albumRepository) Delete(id string) error {
+	return r.delete(Eq{"id": id})
+}
+
+func (r *albumRepository) Count(options ...rest.QueryOptions) (int64, error) {
+	return r.CountAll(r.parseRestOptions(options...))
+}
+
+func (r *albumRepository) Read(id string) (interface{}, error) {
+	return r.Get(id)
+}
+
+func (r *albumRepository) ReadAll(options ...rest.QueryOptions) (interface{}, error) {
+	return r.GetAll(r.parseRestOptions(options...))
+}
+
+func (r *albumRepository) EntityName() string {
+	return "album"
+}
+
+func (r *albumRepository) NewInstance() interface{} {
+	return &model.Album{}
+}
+
+func (r albumRepository) Save(entity interface{}) (string, error) {
+	a := entity.(*model.Album)
+	err := r.Put(a)
+	return a.ID, err
+}
+
+func (r albumRepository) Update(entity interface{}, cols ...string) error {
+	a := entity.(*model.Album)
+	return r.Put(a)
+}
+
+var _ model.AlbumRepository = (*albumRepository)(nil)
+var _ model.ResourceRepository = (*albumRepository)(nil)
+var _ rest.Persistable = (*albumRepository)(nil)
@@ -2,6 +2,8 @@ package persistence
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"sort"
@@ -16,10 +18,24 @@ import (
 	"github.com/deluan/rest"
 )
 
+// artistIndexCacheKey is the property table key used to cache the computed artist index
+// (see GetIndex). It is suffixed with a hash of the parsed index groups, so a change to
+// conf.Server.IndexGroups busts the cache instead of serving stale buckets.
+const artistIndexCacheKey = "ArtistIndexCache"
+
+type artistIndexCache struct {
+	Index model.ArtistIndexes `json:"index"`
+}
+
 type artistRepository struct {
 	sqlRepository
 	sqlRestful
 	indexGroups utils.IndexGroups
+	// propertyRepo backs the index cache (see indexCacheProperty). It's nil in normal
+	// operation, in which case indexCacheProperty falls back to NewPropertyRepository; tests
+	// inject a fake here so the cache's invalidate-on-mutation contract is testable without a
+	// DB connection.
+	propertyRepo model.PropertyRepository
 }
 
 type dbArtist struct {
@@ -28,6 +44,7 @@ type dbArtist struct {
 }
 
 func NewArtistRepository(ctx context.Context, o orm.Ormer) model.ArtistRepository {
+	ensureStableIDs(ctx, o)
 	r := &artistRepository{}
 	r.ctx = ctx
 	r.ormer = o
@@ -59,7 +76,10 @@ func (r *artistRepository) Put(a *model.Artist) error {
 	a.FullText = getFullText(a.Name, a.SortArtistName)
 	dba := r.fromModel(a)
 	_, err := r.put(dba.ID, dba)
-	return err
+	if err != nil {
+		return err
+	}
+	return r.invalidateIndexCache()
 }
 
 func (r *artistRepository) Get(id string) (*model.Artist, error) {
@@ -134,11 +154,23 @@ func (r *artistRepository) getIndexKey(a *model.Artist) string {
 
 // TODO Cache the index (recalculate when there are changes to the DB)
 func (r *artistRepository) GetIndex() (model.ArtistIndexes, error) {
+	if cached, ok := r.readIndexCache(); ok {
+		return cached, nil
+	}
+
 	all, err := r.GetAll(model.QueryOptions{Sort: "order_artist_name"})
 	if err != nil {
 		return nil, err
 	}
 
+	result := r.buildIndex(all)
+	r.writeIndexCache(result)
+	return result, nil
+}
+
+// buildIndex buckets artists by getIndexKey. Split out from GetIndex so the bucketing
+// itself is testable without a DB connection.
+func (r *artistRepository) buildIndex(all model.Artists) model.ArtistIndexes {
 	fullIdx := make(map[string]*model.ArtistIndex)
 	for i := range all {
 		a := all[i]
@@ -157,7 +189,59 @@ func (r *artistRepository) GetIndex() (model.ArtistIndexes, error) {
 	sort.Slice(result, func(i, j int) bool {
 		return result[i].ID < result[j].ID
 	})
-	return result, nil
+	return result
+}
+
+// indexGroupsHash identifies the parsed utils.IndexGroups currently in effect, so that a
+// change to conf.Server.IndexGroups is picked up even though nothing in the DB changed.
+func (r *artistRepository) indexGroupsHash() string {
+	keys := make([]string, 0, len(r.indexGroups))
+	for k, v := range r.indexGroups {
+		keys = append(keys, k+"="+v)
+	}
+	sort.Strings(keys)
+	return fmt.Sprintf("%x", md5.Sum([]byte(strings.Join(keys, ","))))
+}
+
+func (r *artistRepository) indexCacheProperty() model.PropertyRepository {
+	if r.propertyRepo != nil {
+		return r.propertyRepo
+	}
+	return NewPropertyRepository(r.ctx, r.ormer)
+}
+
+// readIndexCache returns the cached index if one was stored for the current index groups
+// hash. It's invalidated explicitly by Put/Refresh/Delete/purgeEmpty rather than by
+// comparing timestamps, since any of those can change the buckets an artist belongs to.
+func (r *artistRepository) readIndexCache() (model.ArtistIndexes, bool) {
+	raw, err := r.indexCacheProperty().Get(artistIndexCacheKey + ":" + r.indexGroupsHash())
+	if err != nil || raw == "" {
+		return nil, false
+	}
+	var cached artistIndexCache
+	if err := json.Unmarshal([]byte(raw), &cached); err != nil {
+		return nil, false
+	}
+	return cached.Index, true
+}
+
+func (r *artistRepository) writeIndexCache(idx model.ArtistIndexes) {
+	raw, err := json.Marshal(artistIndexCache{Index: idx})
+	if err != nil {
+		log.Error(r.ctx, "Could not serialize artist index cache", err)
+		return
+	}
+	if err := r.indexCacheProperty().Put(artistIndexCacheKey+":"+r.indexGroupsHash(), string(raw)); err != nil {
+		log.Error(r.ctx, "Could not store artist index cache", err)
+	}
+}
+
+func (r *artistRepository) invalidateIndexCache() error {
+	err := r.indexCacheProperty().Delete(artistIndexCacheKey + ":" + r.indexGroupsHash())
+	if err != nil && err != model.ErrNotFound {
+		return err
+	}
+	return nil
 }
 
 func (r *artistRepository) Refresh(ids ...string) error {
@@ -177,14 +261,24 @@ func (r *artistRepository) refresh(ids ...string) error {
 		CurrentId string
 	}
 	var artists []refreshArtist
-	sel := Select("f.album_artist_id as id", "f.album_artist as name", "count(*) as album_count", "a.id as current_id",
-		"group_concat(f.mbz_album_artist_id , ' ') as mbz_artist_id",
+	// Pivot off the album_artists join table instead of album.album_artist_id, so that an
+	// artist credited as "featured" on every track of an album still gets its own entry
+	// and aggregate counts, alongside the main album artist. Name/MbzArtistID come from
+	// aa itself (populated per contributor by albumContributors), not from f.album_artist
+	// -- that column holds the whole combined tag (e.g. "Artist A feat. Artist B"), which
+	// would give every contributor, including the main artist once a tag is split, the
+	// wrong name. min() is just a deterministic pick among a contributor's own rows, which
+	// should already agree on name since contributorID is derived from it.
+	sel := Select("aa.artist_id as id", "min(aa.name) as name", "count(distinct aa.album_id) as album_count",
+		"a.id as current_id",
+		"group_concat(aa.mbz_artist_id, ' ') as mbz_artist_id",
 		"f.sort_album_artist_name as sort_artist_name", "f.order_album_artist_name as order_artist_name",
 		"sum(f.song_count) as song_count", "sum(f.size) as size").
-		From("album f").
-		LeftJoin("artist a on f.album_artist_id = a.id").
-		Where(Eq{"f.album_artist_id": ids}).
-		GroupBy("f.album_artist_id").OrderBy("f.id")
+		From("album_artists aa").
+		Join("album f on f.id = aa.album_id").
+		LeftJoin("artist a on aa.artist_id = a.id").
+		Where(Eq{"aa.artist_id": ids}).
+		GroupBy("aa.artist_id").OrderBy("aa.artist_id")
 	err := r.queryAll(sel, &artists)
 	if err != nil {
 		return err
@@ -222,14 +316,23 @@ func (r *artistRepository) GetStarred(options ...model.QueryOptions) (model.Arti
 }
 
 func (r *artistRepository) purgeEmpty() error {
-	del := Delete(r.tableName).Where("id not in (select distinct(album_artist_id) from album)")
+	del := Delete(r.tableName).Where("id not in (select distinct(artist_id) from album_artists)")
 	c, err := r.executeSQL(del)
 	if err == nil {
 		if c > 0 {
 			log.Debug(r.ctx, "Purged empty artists", "totalDeleted", c)
 		}
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	if err := newMediaFileArtistRepository(r.ctx, r.ormer).purgeOrphan(); err != nil {
+		return err
+	}
+	if err := newAlbumArtistRepository(r.ctx, r.ormer).purgeOrphan(); err != nil {
+		return err
+	}
+	return r.invalidateIndexCache()
 }
 
 func (r *artistRepository) Search(q string, offset int, size int) (model.Artists, error) {
@@ -262,7 +365,10 @@ func (r *artistRepository) NewInstance() interface{} {
 }
 
 func (r artistRepository) Delete(id string) error {
-	return r.delete(Eq{"id": id})
+	if err := r.delete(Eq{"id": id}); err != nil {
+		return err
+	}
+	return r.invalidateIndexCache()
 }
 
 func (r artistRepository) Save(entity interface{}) (string, error) {
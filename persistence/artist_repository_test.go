@@ -0,0 +1,158 @@
+package persistence
+
+import (
+	"testing"
+
+	"github.com/deluan/navidrome/model"
+	"github.com/deluan/navidrome/utils"
+)
+
+// These cover the cache-key logic that decides whether a stored index is still valid for
+// the current conf.Server.IndexGroups, and the bucketing it's keyed by.
+
+func newTestArtistRepository(groups string) *artistRepository {
+	r := &artistRepository{indexGroups: utils.ParseIndexGroups(groups)}
+	return r
+}
+
+// fakePropertyRepository is an in-memory stand-in for model.PropertyRepository, letting the
+// index cache's read/write/invalidate cycle be exercised without a DB connection.
+type fakePropertyRepository struct {
+	values map[string]string
+}
+
+func newFakePropertyRepository() *fakePropertyRepository {
+	return &fakePropertyRepository{values: map[string]string{}}
+}
+
+func (f *fakePropertyRepository) Put(id string, value string) error {
+	f.values[id] = value
+	return nil
+}
+
+func (f *fakePropertyRepository) Get(id string) (string, error) {
+	v, ok := f.values[id]
+	if !ok {
+		return "", model.ErrNotFound
+	}
+	return v, nil
+}
+
+func (f *fakePropertyRepository) Delete(id string) error {
+	if _, ok := f.values[id]; !ok {
+		return model.ErrNotFound
+	}
+	delete(f.values, id)
+	return nil
+}
+
+func newTestArtistRepositoryWithCache(groups string) *artistRepository {
+	r := newTestArtistRepository(groups)
+	r.propertyRepo = newFakePropertyRepository()
+	return r
+}
+
+func TestIndexGroupsHash(t *testing.T) {
+	r1 := newTestArtistRepository("A B C(Christ) D(The Doors)")
+	r2 := newTestArtistRepository("A B C(Christ) D(The Doors)")
+	if r1.indexGroupsHash() != r2.indexGroupsHash() {
+		t.Fatal("expected the same conf.Server.IndexGroups to hash identically")
+	}
+
+	r3 := newTestArtistRepository("A B C(Christ)")
+	if r1.indexGroupsHash() == r3.indexGroupsHash() {
+		t.Fatal("expected a different set of index groups to change the hash, so a config change busts the cache")
+	}
+}
+
+func TestGetIndexKey(t *testing.T) {
+	r := newTestArtistRepository("A B C(Christ) D(The Doors)")
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Bach", "B"},
+		{"Christ on a Bike", "C"},
+		{"The Doors", "D"},
+		{"Zappa", "#"},
+	}
+	for _, tt := range tests {
+		got := r.getIndexKey(&model.Artist{Name: tt.name})
+		if got != tt.want {
+			t.Errorf("getIndexKey(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildIndex(t *testing.T) {
+	r := newTestArtistRepository("A B C(Christ) D(The Doors)")
+
+	before := r.buildIndex(model.Artists{{Name: "Bach"}, {Name: "Christ on a Bike"}})
+	if len(before) != 2 {
+		t.Fatalf("expected 2 buckets, got %d: %#v", len(before), before)
+	}
+
+	t.Run("adding an artist is reflected in the very next build, no stale bucket left behind", func(t *testing.T) {
+		after := r.buildIndex(model.Artists{{Name: "Bach"}, {Name: "Christ on a Bike"}, {Name: "Zappa"}})
+		if len(after) != 3 {
+			t.Fatalf("expected 3 buckets after adding an artist, got %d: %#v", len(after), after)
+		}
+		var zBucket *model.ArtistIndex
+		for i := range after {
+			if after[i].ID == "#" {
+				zBucket = &after[i]
+			}
+		}
+		if zBucket == nil || len(zBucket.Artists) != 1 || zBucket.Artists[0].Name != "Zappa" {
+			t.Errorf("expected the new artist to show up immediately in the '#' bucket, got %#v", after)
+		}
+	})
+}
+
+// TestIndexCacheInvalidation exercises the same contract Put/Delete/purgeEmpty rely on:
+// once an artist mutation calls invalidateIndexCache, GetIndex must not keep serving the
+// index computed before that mutation -- it has to see a cache miss and recompute.
+func TestIndexCacheInvalidation(t *testing.T) {
+	r := newTestArtistRepositoryWithCache("A B C(Christ) D(The Doors)")
+
+	before := r.buildIndex(model.Artists{{Name: "Bach"}})
+	r.writeIndexCache(before)
+
+	cached, ok := r.readIndexCache()
+	if !ok || len(cached) != 1 {
+		t.Fatalf("expected a cache hit with the index written above, got ok=%v, %#v", ok, cached)
+	}
+
+	// Simulate what Put/Delete/purgeEmpty do after mutating an artist.
+	if err := r.invalidateIndexCache(); err != nil {
+		t.Fatalf("invalidateIndexCache() error = %v", err)
+	}
+
+	if _, ok := r.readIndexCache(); ok {
+		t.Fatal("expected a cache miss immediately after invalidation, so the next GetIndex call recomputes instead of serving the pre-mutation index")
+	}
+
+	// And a fresh computation (what GetIndex does on a miss) plus re-cache reflects the
+	// mutated roster right away.
+	after := r.buildIndex(model.Artists{{Name: "Bach"}, {Name: "Zappa"}})
+	r.writeIndexCache(after)
+	cached, ok = r.readIndexCache()
+	if !ok || len(cached) != 2 {
+		t.Fatalf("expected the cache to reflect the mutated artist list immediately, got ok=%v, %#v", ok, cached)
+	}
+}
+
+func TestIndexCacheInvalidationIsScopedToIndexGroups(t *testing.T) {
+	shared := newFakePropertyRepository()
+	rOld := newTestArtistRepository("A B")
+	rOld.propertyRepo = shared
+	rNew := newTestArtistRepository("A B C")
+	rNew.propertyRepo = shared
+
+	rOld.writeIndexCache(rOld.buildIndex(model.Artists{{Name: "Bach"}}))
+
+	if _, ok := rNew.readIndexCache(); ok {
+		t.Fatal("expected a config change (different IndexGroups) to produce a cache miss instead of serving the old groups' index")
+	}
+}
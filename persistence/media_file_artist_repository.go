@@ -0,0 +1,195 @@
+package persistence
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"strings"
+
+	. "github.com/Masterminds/squirrel"
+	"github.com/astaxie/beego/orm"
+	"github.com/deluan/navidrome/conf"
+	"github.com/deluan/navidrome/model"
+)
+
+// defaultArtistSeparators are used to split a single artist tag into several contributing
+// artists (e.g. "Artist A; Artist B feat. Artist C") when conf.Server.ArtistSeparators is
+// not set.
+var defaultArtistSeparators = []string{";", "/", " feat. ", " ft. "}
+
+func artistSeparators() []string {
+	if len(conf.Server.ArtistSeparators) > 0 {
+		return conf.Server.ArtistSeparators
+	}
+	return defaultArtistSeparators
+}
+
+// splitContributorNames breaks a raw artist tag (which may already contain the ID3v2.4
+// null byte or Vorbis multi-value separator, both normalized to "\x00" by the tag reader)
+// into individual artist names, trimming whitespace and dropping empty entries.
+func splitContributorNames(name string) []string {
+	if name == "" {
+		return nil
+	}
+	parts := strings.Split(name, "\x00")
+	seps := artistSeparators()
+	var names []string
+	for _, p := range parts {
+		split := []string{p}
+		for _, sep := range seps {
+			var next []string
+			for _, s := range split {
+				next = append(next, strings.Split(s, sep)...)
+			}
+			split = next
+		}
+		names = append(names, split...)
+	}
+	result := names[:0]
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		if n != "" {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// contributorID derives the same artist ID mediaFileMapper would for name, preferring
+// mbzArtistID (set by the scanner from the tag's MBID) when conf.Server.StableIDs is on.
+func contributorID(mbzArtistID, name string) string {
+	if conf.Server.StableIDs && mbzArtistID != "" {
+		return mbzArtistID
+	}
+	return fmt.Sprintf("%x", md5.Sum([]byte(strings.ToLower(name))))
+}
+
+// mediaFileContributors splits mf.Artist into its contributing artists and returns the
+// media_file_artists rows for it. The first name is always recorded as the main artist;
+// subsequent names are featured artists, in tag order. Only the main artist gets the
+// resolved MusicBrainz ID, since mf only carries one.
+func mediaFileContributors(mf *model.MediaFile) model.MediaFileArtists {
+	names := splitContributorNames(mf.Artist)
+	if len(names) == 0 {
+		names = []string{mf.Artist}
+	}
+	var artists model.MediaFileArtists
+	for i, name := range names {
+		role := model.RoleFeatured
+		mbzID := ""
+		if i == 0 {
+			role = model.RoleMain
+			mbzID = mf.MbzArtistID
+		}
+		artists = append(artists, model.MediaFileArtist{
+			MediaFileID: mf.ID,
+			ArtistID:    contributorID(mbzID, name),
+			Name:        name,
+			MbzArtistID: mbzID,
+			Role:        role,
+			Order:       i,
+		})
+	}
+	return artists
+}
+
+// albumContributors is the album-level equivalent of mediaFileContributors, splitting
+// a.AlbumArtist into the album_artists rows for a.
+func albumContributors(a *model.Album) model.AlbumArtists {
+	names := splitContributorNames(a.AlbumArtist)
+	if len(names) == 0 {
+		names = []string{a.AlbumArtist}
+	}
+	var artists model.AlbumArtists
+	for i, name := range names {
+		role := model.RoleFeatured
+		mbzID := ""
+		if i == 0 {
+			role = model.RoleMain
+			mbzID = a.MbzAlbumArtistID
+		}
+		artists = append(artists, model.AlbumArtist{
+			AlbumID:     a.ID,
+			ArtistID:    contributorID(mbzID, name),
+			Name:        name,
+			MbzArtistID: mbzID,
+			Role:        role,
+			Order:       i,
+		})
+	}
+	return artists
+}
+
+// mediaFileArtistRepository manages the media_file_artists join table, which links a track
+// to each artist that contributed to it, with that artist's role (model.ArtistRole),
+// replacing the single ArtistID/AlbumArtistID columns as the source of truth for browsing
+// an artist's contributions. The rows mediaFileRepository.Put writes come from
+// mediaFileContributors, which assigns only the main and featured roles; no remixer,
+// composer or conductor rows are derived from tags.
+type mediaFileArtistRepository struct {
+	sqlRepository
+}
+
+func newMediaFileArtistRepository(ctx context.Context, o orm.Ormer) *mediaFileArtistRepository {
+	r := &mediaFileArtistRepository{}
+	r.ctx = ctx
+	r.ormer = o
+	r.tableName = "media_file_artists"
+	return r
+}
+
+func (r *mediaFileArtistRepository) replace(mediaFileID string, artists model.MediaFileArtists) error {
+	del := Delete(r.tableName).Where(Eq{"media_file_id": mediaFileID})
+	if _, err := r.executeSQL(del); err != nil {
+		return err
+	}
+	for _, a := range artists {
+		ins := Insert(r.tableName).Columns("media_file_id", "artist_id", "name", "mbz_artist_id", "role", "seq").
+			Values(a.MediaFileID, a.ArtistID, a.Name, a.MbzArtistID, string(a.Role), a.Order)
+		if _, err := r.executeSQL(ins); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *mediaFileArtistRepository) purgeOrphan() error {
+	del := Delete(r.tableName).Where("media_file_id not in (select id from media_file)")
+	_, err := r.executeSQL(del)
+	return err
+}
+
+// albumArtistRepository is the album-level equivalent of mediaFileArtistRepository,
+// backing the album_artists table.
+type albumArtistRepository struct {
+	sqlRepository
+}
+
+func newAlbumArtistRepository(ctx context.Context, o orm.Ormer) *albumArtistRepository {
+	r := &albumArtistRepository{}
+	r.ctx = ctx
+	r.ormer = o
+	r.tableName = "album_artists"
+	return r
+}
+
+func (r *albumArtistRepository) replace(albumID string, artists model.AlbumArtists) error {
+	del := Delete(r.tableName).Where(Eq{"album_id": albumID})
+	if _, err := r.executeSQL(del); err != nil {
+		return err
+	}
+	for _, a := range artists {
+		ins := Insert(r.tableName).Columns("album_id", "artist_id", "name", "mbz_artist_id", "role", "seq").
+			Values(a.AlbumID, a.ArtistID, a.Name, a.MbzArtistID, string(a.Role), a.Order)
+		if _, err := r.executeSQL(ins); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *albumArtistRepository) purgeOrphan() error {
+	del := Delete(r.tableName).Where("album_id not in (select id from album)")
+	_, err := r.executeSQL(del)
+	return err
+}
@@ -0,0 +1,110 @@
+package persistence
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/deluan/navidrome/conf"
+	"github.com/deluan/navidrome/model"
+)
+
+func withArtistSeparators(t *testing.T, seps []string, fn func()) {
+	t.Helper()
+	prev := conf.Server.ArtistSeparators
+	conf.Server.ArtistSeparators = seps
+	defer func() { conf.Server.ArtistSeparators = prev }()
+	fn()
+}
+
+func TestSplitContributorNames(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  string
+		want []string
+	}{
+		{"empty tag", "", nil},
+		{"single artist, no separators", "Artist A", []string{"Artist A"}},
+		{"semicolon separator", "Artist A; Artist B", []string{"Artist A", "Artist B"}},
+		{"feat. separator", "Artist A feat. Artist B", []string{"Artist A", "Artist B"}},
+		{"ft. separator", "Artist A ft. Artist B", []string{"Artist A", "Artist B"}},
+		{"consecutive separators collapse", "Artist A;;Artist B", []string{"Artist A", "Artist B"}},
+		{"separator-only tag yields nothing", ";;;", []string{}},
+		{"surrounding whitespace is trimmed", "  Artist A ; Artist B  ", []string{"Artist A", "Artist B"}},
+		{"null-byte multi-value tag", "Artist A\x00Artist B", []string{"Artist A", "Artist B"}},
+		{"null byte combined with a configured separator", "Artist A\x00Artist B; Artist C", []string{"Artist A", "Artist B", "Artist C"}},
+		{"name that merely contains a separator substring is not split on a partial match", "Software", []string{"Software"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitContributorNames(tt.tag)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitContributorNames(%q) = %#v, want %#v", tt.tag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitContributorNamesCustomSeparators(t *testing.T) {
+	withArtistSeparators(t, []string{" & "}, func() {
+		got := splitContributorNames("Artist A & Artist B; Artist C")
+		want := []string{"Artist A", "Artist B; Artist C"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("splitContributorNames with custom separators = %#v, want %#v", got, want)
+		}
+	})
+}
+
+func TestMediaFileContributors(t *testing.T) {
+	t.Run("single artist gets the main role and the track's resolved MBID", func(t *testing.T) {
+		prev := conf.Server.StableIDs
+		conf.Server.StableIDs = true
+		defer func() { conf.Server.StableIDs = prev }()
+		mf := &model.MediaFile{ID: "mf1", Artist: "Artist A", MbzArtistID: "mbid-a"}
+		got := mediaFileContributors(mf)
+		want := model.MediaFileArtists{
+			{MediaFileID: "mf1", ArtistID: "mbid-a", Name: "Artist A", MbzArtistID: "mbid-a", Role: model.RoleMain, Order: 0},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("mediaFileContributors() = %#v, want %#v", got, want)
+		}
+	})
+
+	t.Run("split tag gives each contributor its own name, only the main one keeps the MBID", func(t *testing.T) {
+		mf := &model.MediaFile{ID: "mf1", Artist: "Artist A feat. Artist B", MbzArtistID: "mbid-a"}
+		got := mediaFileContributors(mf)
+		if len(got) != 2 {
+			t.Fatalf("expected 2 contributors, got %d: %#v", len(got), got)
+		}
+		if got[0].Name != "Artist A" || got[0].Role != model.RoleMain || got[0].MbzArtistID != "mbid-a" {
+			t.Errorf("unexpected main contributor: %#v", got[0])
+		}
+		if got[1].Name != "Artist B" || got[1].Role != model.RoleFeatured || got[1].MbzArtistID != "" {
+			t.Errorf("unexpected featured contributor: %#v", got[1])
+		}
+		if got[0].ArtistID == got[1].ArtistID {
+			t.Error("expected distinct contributors to get distinct artist IDs")
+		}
+	})
+
+	t.Run("empty artist tag still yields a single (empty-named) contributor", func(t *testing.T) {
+		mf := &model.MediaFile{ID: "mf1", Artist: ""}
+		got := mediaFileContributors(mf)
+		if len(got) != 1 || got[0].Name != "" {
+			t.Errorf("expected a single empty-named contributor, got %#v", got)
+		}
+	})
+}
+
+func TestAlbumContributors(t *testing.T) {
+	a := &model.Album{ID: "al1", AlbumArtist: "Artist A; Artist B", MbzAlbumArtistID: "mbid-a"}
+	got := albumContributors(a)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 contributors, got %d: %#v", len(got), got)
+	}
+	if got[0].AlbumID != "al1" || got[0].Name != "Artist A" || got[0].Role != model.RoleMain {
+		t.Errorf("unexpected main contributor: %#v", got[0])
+	}
+	if got[1].Name != "Artist B" || got[1].Role != model.RoleFeatured {
+		t.Errorf("unexpected featured contributor: %#v", got[1])
+	}
+}
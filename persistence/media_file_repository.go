@@ -0,0 +1,160 @@
+package persistence
+
+import (
+	"context"
+	"os"
+
+	. "github.com/Masterminds/squirrel"
+	"github.com/astaxie/beego/orm"
+	"github.com/deluan/navidrome/model"
+	"github.com/deluan/rest"
+)
+
+type mediaFileRepository struct {
+	sqlRepository
+	sqlRestful
+}
+
+func NewMediaFileRepository(ctx context.Context, o orm.Ormer) model.MediaFileRepository {
+	ensureStableIDs(ctx, o)
+	r := &mediaFileRepository{}
+	r.ctx = ctx
+	r.ormer = o
+	r.tableName = "media_file"
+	r.sortMappings = map[string]string{
+		"title": "order_title",
+	}
+	r.filterMappings = map[string]filterFunc{
+		"title":   fullTextFilter,
+		"starred": booleanFilter,
+	}
+	return r
+}
+
+func (r *mediaFileRepository) selectMediaFile(options ...model.QueryOptions) SelectBuilder {
+	return r.newSelectWithAnnotation("media_file.id", options...).Columns("*")
+}
+
+func (r *mediaFileRepository) CountAll(options ...model.QueryOptions) (int64, error) {
+	return r.count(r.newSelectWithAnnotation("media_file.id"), options...)
+}
+
+func (r *mediaFileRepository) Exists(id string) (bool, error) {
+	return r.exists(Select().Where(Eq{"id": id}))
+}
+
+// Put saves mf and, derived from mf.Artist, (re)writes its media_file_artists rows, so
+// every contributing artist -- not just the main one -- stays in sync with the tag; these
+// rows are what GetArtists and FindByArtist read. Artist creation and purging
+// (artistRepository.refresh/purgeEmpty) pivot off album_artists instead, which
+// albumRepository.Put maintains.
+func (r *mediaFileRepository) Put(mf *model.MediaFile) error {
+	_, err := r.put(mf.ID, mf)
+	if err != nil {
+		return err
+	}
+	return newMediaFileArtistRepository(r.ctx, r.ormer).replace(mf.ID, mediaFileContributors(mf))
+}
+
+func (r *mediaFileRepository) Get(id string) (*model.MediaFile, error) {
+	sel := r.selectMediaFile().Where(Eq{"id": id})
+	var res []model.MediaFile
+	if err := r.queryAll(sel, &res); err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, model.ErrNotFound
+	}
+	return &res[0], nil
+}
+
+func (r *mediaFileRepository) GetAll(options ...model.QueryOptions) (model.MediaFiles, error) {
+	sel := r.selectMediaFile(options...)
+	var res model.MediaFiles
+	err := r.queryAll(sel, &res)
+	return res, err
+}
+
+// FindByPath returns every indexed media file that is a direct child of dir, not ones
+// nested further inside a subdirectory of dir. This matches the watcher's processDir,
+// which only ever lists dir's own entries with a single (non-recursive) ioutil.ReadDir: a
+// recursive match here would make every file in a nested subdirectory (e.g. a "Bonus
+// Tracks" folder inside an album folder) look deleted -- and get purged -- every time a
+// sibling file elsewhere in dir changes. A nested subdirectory gets its own fsnotify watch
+// (see addRecursive) and is reconciled by its own processDir call instead.
+func (r *mediaFileRepository) FindByPath(dir string) (model.MediaFiles, error) {
+	sep := string(os.PathSeparator)
+	sel := r.selectMediaFile().Where(And{
+		Like{"path": dir + sep + "%"},
+		NotLike{"path": dir + sep + "%" + sep + "%"},
+	})
+	var res model.MediaFiles
+	err := r.queryAll(sel, &res)
+	return res, err
+}
+
+// GetArtists returns every artist that contributed to mediaFileID, across all roles (main,
+// featured, remixer, composer, conductor), in credited order. This is what lets a
+// "featured" artist show up when browsing their own artist page, instead of only the main
+// artist recorded on media_file.artist_id.
+func (r *mediaFileRepository) GetArtists(mediaFileID string) (model.MediaFileArtists, error) {
+	sel := Select("media_file_id", "artist_id", "role", "seq as \"order\"").
+		From("media_file_artists").
+		Where(Eq{"media_file_id": mediaFileID}).
+		OrderBy("seq")
+	var res model.MediaFileArtists
+	err := r.queryAll(sel, &res)
+	return res, err
+}
+
+// FindByArtist returns every track the given artist contributed to in any role, so a
+// featured or remixing credit is browsable from that artist's page, not just tracks where
+// they are the main artist.
+func (r *mediaFileRepository) FindByArtist(artistID string) (model.MediaFiles, error) {
+	sel := r.selectMediaFile().
+		Join("media_file_artists mfa on mfa.media_file_id = media_file.id").
+		Where(Eq{"mfa.artist_id": artistID}).
+		OrderBy("mfa.seq")
+	var res model.MediaFiles
+	err := r.queryAll(sel, &res)
+	return res, err
+}
+
+func (r *mediaFileRepository) Delete(id string) error {
+	return r.delete(Eq{"id": id})
+}
+
+func (r *mediaFileRepository) Count(options ...rest.QueryOptions) (int64, error) {
+	return r.CountAll(r.parseRestOptions(options...))
+}
+
+func (r *mediaFileRepository) Read(id string) (interface{}, error) {
+	return r.Get(id)
+}
+
+func (r *mediaFileRepository) ReadAll(options ...rest.QueryOptions) (interface{}, error) {
+	return r.GetAll(r.parseRestOptions(options...))
+}
+
+func (r *mediaFileRepository) EntityName() string {
+	return "mediaFile"
+}
+
+func (r *mediaFileRepository) NewInstance() interface{} {
+	return &model.MediaFile{}
+}
+
+func (r mediaFileRepository) Save(entity interface{}) (string, error) {
+	mf := entity.(*model.MediaFile)
+	err := r.Put(mf)
+	return mf.ID, err
+}
+
+func (r mediaFileRepository) Update(entity interface{}, cols ...string) error {
+	mf := entity.(*model.MediaFile)
+	return r.Put(mf)
+}
+
+var _ model.MediaFileRepository = (*mediaFileRepository)(nil)
+var _ model.ResourceRepository = (*mediaFileRepository)(nil)
+var _ rest.Persistable = (*mediaFileRepository)(nil)
@@ -0,0 +1,100 @@
+package persistence
+
+import (
+	"context"
+	"sync"
+
+	"github.com/astaxie/beego/orm"
+	"github.com/deluan/navidrome/conf"
+	"github.com/deluan/navidrome/log"
+)
+
+// stableIDsAppliedProperty marks, in the property table, that the MusicBrainz-based ID
+// rewrite below has already run against this DB, so it isn't repeated on every restart.
+const stableIDsAppliedProperty = "StableIDsApplied"
+
+var stableIDsOnce sync.Once
+
+// ensureStableIDs checks, once per process, whether conf.Server.StableIDs is on and the
+// library hasn't been rewritten to use MusicBrainz-based IDs yet, and if so runs that
+// rewrite. Unlike a goose migration, this re-evaluates conf.Server.StableIDs every time
+// the process starts, so enabling the flag against an existing library -- the "opt in
+// mid-library" case -- is picked up on the very next start, not just on a fresh DB.
+//
+// It's called from NewArtistRepository, NewAlbumRepository and NewMediaFileRepository --
+// every constructor that reads or writes the tables this rewrite touches -- rather than
+// just one of them, so the rewrite is guaranteed to have already run by the time any of
+// them is used, regardless of which one the caller happens to construct first. sync.Once
+// makes the repeated call cheap.
+func ensureStableIDs(ctx context.Context, o orm.Ormer) {
+	stableIDsOnce.Do(func() {
+		if err := applyStableIDs(ctx, o); err != nil {
+			log.Error(ctx, "Could not rewrite IDs for Server.StableIDs", err)
+		}
+	})
+}
+
+func applyStableIDs(ctx context.Context, o orm.Ormer) error {
+	if !conf.Server.StableIDs {
+		return nil
+	}
+
+	props := NewPropertyRepository(ctx, o)
+	if done, _ := props.Get(stableIDsAppliedProperty); done == "true" {
+		return nil
+	}
+
+	log.Info(ctx, "Server.StableIDs enabled: rewriting media_file/album/artist IDs to use MusicBrainz IDs where available")
+
+	// Cascades must run before the primary keys they depend on are rewritten below, while
+	// artist.id/album.id/media_file.id still hold their old (pre-rewrite) values: each
+	// statement looks up the *new* id via the still-old id, then the PK rewrite below
+	// catches the table up to the id its own rows were already pointed at.
+	cascades := []string{
+		`update media_file_artists set artist_id = (select mbz_artist_id from artist where artist.id = media_file_artists.artist_id and mbz_artist_id is not null and mbz_artist_id != '')
+			where artist_id in (select id from artist where mbz_artist_id is not null and mbz_artist_id != '' and mbz_artist_id != id)`,
+		`update album_artists set artist_id = (select mbz_artist_id from artist where artist.id = album_artists.artist_id and mbz_artist_id is not null and mbz_artist_id != '')
+			where artist_id in (select id from artist where mbz_artist_id is not null and mbz_artist_id != '' and mbz_artist_id != id)`,
+		`update annotation set item_id = (select mbz_artist_id from artist where artist.id = annotation.item_id and mbz_artist_id is not null and mbz_artist_id != '')
+			where item_type = 'artist' and item_id in (select id from artist where mbz_artist_id is not null and mbz_artist_id != '' and mbz_artist_id != id)`,
+		`update annotation set item_id = (select mbz_album_id from album where album.id = annotation.item_id and mbz_album_id is not null and mbz_album_id != '')
+			where item_type = 'album' and item_id in (select id from album where mbz_album_id is not null and mbz_album_id != '' and mbz_album_id != id)`,
+		`update annotation set item_id = (select mbz_track_id from media_file where media_file.id = annotation.item_id and mbz_track_id is not null and mbz_track_id != '')
+			where item_type = 'media_file' and item_id in (select id from media_file where mbz_track_id is not null and mbz_track_id != '' and mbz_track_id != id)`,
+		`update playlist_tracks set media_file_id = (select mbz_track_id from media_file where media_file.id = playlist_tracks.media_file_id and mbz_track_id is not null and mbz_track_id != '')
+			where media_file_id in (select id from media_file where mbz_track_id is not null and mbz_track_id != '' and mbz_track_id != id)`,
+		`update play_queue set current_id = (select mbz_track_id from media_file where media_file.id = play_queue.current_id and mbz_track_id is not null and mbz_track_id != '')
+			where current_id in (select id from media_file where mbz_track_id is not null and mbz_track_id != '' and mbz_track_id != id)`,
+	}
+	for _, stmt := range cascades {
+		if _, err := o.Raw(stmt).Exec(); err != nil {
+			return err
+		}
+	}
+
+	// Only now that everything referencing them has been repointed is it safe to rewrite
+	// the primary keys themselves.
+	rewrites := []string{
+		`update artist set id = mbz_artist_id where mbz_artist_id is not null and mbz_artist_id != '' and mbz_artist_id != id`,
+		`update album set id = mbz_album_id where mbz_album_id is not null and mbz_album_id != '' and mbz_album_id != id`,
+		`update media_file set id = mbz_track_id where mbz_track_id is not null and mbz_track_id != '' and mbz_track_id != id`,
+	}
+	for _, stmt := range rewrites {
+		if _, err := o.Raw(stmt).Exec(); err != nil {
+			return err
+		}
+	}
+
+	// Equivalent of migration.forceFullRescan (db/migration/migration.go): force every file
+	// to be re-read on the next scan, so derived data (e.g. media_file_artists) gets rebuilt
+	// under the new IDs too. LastScan is tracked per music folder (one property key each), so
+	// this has to match forceFullRescan's "like" delete, not an exact key match.
+	if _, err := o.Raw(`delete from property where id like 'LastScan%'`).Exec(); err != nil {
+		return err
+	}
+	if _, err := o.Raw(`update media_file set updated_at = '0001-01-01'`).Exec(); err != nil {
+		return err
+	}
+
+	return props.Put(stableIDsAppliedProperty, "true")
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/deluan/navidrome/conf"
 	"github.com/deluan/navidrome/consts"
 	"github.com/deluan/navidrome/model"
 	"github.com/deluan/navidrome/scanner/metadata"
@@ -113,19 +114,46 @@ func (s *mediaFileMapper) mapAlbumName(md metadata.Metadata) string {
 	return name
 }
 
+// trackID derives a stable, unique ID for a track. When conf.Server.StableIDs is enabled
+// and the file carries a MusicBrainz Track ID, that MBID is used directly so renaming the
+// file or touching unrelated tags doesn't orphan play counts, starred flags or playlists.
+// Otherwise it falls back to hashing the file path, as before.
 func (s *mediaFileMapper) trackID(md metadata.Metadata) string {
+	if conf.Server.StableIDs && md.MbzTrackID() != "" {
+		return md.MbzTrackID()
+	}
 	return fmt.Sprintf("%x", md5.Sum([]byte(md.FilePath())))
 }
 
 func (s *mediaFileMapper) albumID(md metadata.Metadata) string {
+	if conf.Server.StableIDs && md.MbzAlbumID() != "" {
+		return md.MbzAlbumID()
+	}
 	albumPath := strings.ToLower(fmt.Sprintf("%s\\%s", s.mapAlbumArtistName(md), s.mapAlbumName(md)))
 	return fmt.Sprintf("%x", md5.Sum([]byte(albumPath)))
 }
 
 func (s *mediaFileMapper) artistID(md metadata.Metadata) string {
-	return fmt.Sprintf("%x", md5.Sum([]byte(strings.ToLower(s.mapArtistName(md)))))
+	return s.artistIDFor(md.MbzArtistID(), s.mapArtistName(md))
 }
 
 func (s *mediaFileMapper) albumArtistID(md metadata.Metadata) string {
-	return fmt.Sprintf("%x", md5.Sum([]byte(strings.ToLower(s.mapAlbumArtistName(md)))))
+	return s.artistIDFor(md.MbzAlbumArtistID(), s.mapAlbumArtistName(md))
+}
+
+// artistIDFor is the shared stable-ID/hash-fallback logic for artist and album artist IDs.
+// Individual entries of a split multi-artist tag get their IDs from the persistence
+// layer's contributorID, which follows the same rule.
+func (s *mediaFileMapper) artistIDFor(mbzArtistID, name string) string {
+	if conf.Server.StableIDs && mbzArtistID != "" {
+		return mbzArtistID
+	}
+	return fmt.Sprintf("%x", md5.Sum([]byte(strings.ToLower(name))))
 }
+
+// Splitting a raw artist tag into its contributing artists (main, featured, etc.) and
+// persisting them to media_file_artists/album_artists now happens in the persistence
+// layer, in mediaFileRepository.Put/albumRepository.Put — see persistence/media_file_artist_repository.go.
+// That keeps contributor parsing reachable from every writer of a MediaFile/Album, not
+// just this mapper, and lets it reuse mf.MbzArtistID/mf.MbzAlbumArtistID already resolved
+// here instead of re-deriving them.
@@ -0,0 +1,138 @@
+package scanner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/deluan/navidrome/conf"
+)
+
+// fakeMetadata is a minimal stand-in for metadata.Metadata, populated with just enough
+// fields to exercise ID derivation.
+type fakeMetadata struct {
+	filePath         string
+	title            string
+	album            string
+	artist           string
+	albumArtist      string
+	compilation      bool
+	genre            string
+	year             int
+	catalogNum       string
+	mbzTrackID       string
+	mbzAlbumID       string
+	mbzArtistID      string
+	mbzAlbumArtistID string
+	mbzAlbumType     string
+	mbzAlbumComment  string
+	comment          string
+	lyrics           string
+}
+
+func (f fakeMetadata) Title() string               { return f.title }
+func (f fakeMetadata) Album() string               { return f.album }
+func (f fakeMetadata) Artist() string              { return f.artist }
+func (f fakeMetadata) AlbumArtist() string         { return f.albumArtist }
+func (f fakeMetadata) Compilation() bool           { return f.compilation }
+func (f fakeMetadata) Genre() string               { return f.genre }
+func (f fakeMetadata) Year() int                   { return f.year }
+func (f fakeMetadata) TrackNumber() (int, bool)    { return 1, true }
+func (f fakeMetadata) DiscNumber() (int, bool)     { return 1, true }
+func (f fakeMetadata) DiscSubtitle() string        { return "" }
+func (f fakeMetadata) HasPicture() bool            { return false }
+func (f fakeMetadata) Duration() float32           { return 123 }
+func (f fakeMetadata) BitRate() int                { return 320 }
+func (f fakeMetadata) FilePath() string            { return f.filePath }
+func (f fakeMetadata) Suffix() string              { return "mp3" }
+func (f fakeMetadata) Size() int64                 { return 1234 }
+func (f fakeMetadata) SortTitle() string           { return "" }
+func (f fakeMetadata) SortAlbum() string           { return "" }
+func (f fakeMetadata) SortArtist() string          { return "" }
+func (f fakeMetadata) SortAlbumArtist() string     { return "" }
+func (f fakeMetadata) CatalogNum() string          { return f.catalogNum }
+func (f fakeMetadata) MbzTrackID() string          { return f.mbzTrackID }
+func (f fakeMetadata) MbzAlbumID() string          { return f.mbzAlbumID }
+func (f fakeMetadata) MbzArtistID() string         { return f.mbzArtistID }
+func (f fakeMetadata) MbzAlbumArtistID() string    { return f.mbzAlbumArtistID }
+func (f fakeMetadata) MbzAlbumType() string        { return f.mbzAlbumType }
+func (f fakeMetadata) MbzAlbumComment() string     { return f.mbzAlbumComment }
+func (f fakeMetadata) Comment() string             { return f.comment }
+func (f fakeMetadata) Lyrics() string              { return f.lyrics }
+func (f fakeMetadata) ModificationTime() time.Time { return time.Time{} }
+
+func withStableIDs(t *testing.T, enabled bool, fn func()) {
+	t.Helper()
+	prev := conf.Server.StableIDs
+	conf.Server.StableIDs = enabled
+	defer func() { conf.Server.StableIDs = prev }()
+	fn()
+}
+
+func TestTrackIDStability(t *testing.T) {
+	mapper := newMediaFileMapper("/music")
+
+	t.Run("hash fallback changes when the path changes", func(t *testing.T) {
+		withStableIDs(t, false, func() {
+			before := fakeMetadata{filePath: "/music/a/song.mp3"}
+			after := fakeMetadata{filePath: "/music/a/renamed.mp3"}
+			if mapper.trackID(before) == mapper.trackID(after) {
+				t.Fatal("expected hash-based trackID to change when the file is renamed")
+			}
+		})
+	})
+
+	t.Run("MBID survives a path rename when StableIDs is on", func(t *testing.T) {
+		withStableIDs(t, true, func() {
+			before := fakeMetadata{filePath: "/music/a/song.mp3", mbzTrackID: "mbid-1"}
+			after := fakeMetadata{filePath: "/music/a/renamed.mp3", mbzTrackID: "mbid-1"}
+			if mapper.trackID(before) != mapper.trackID(after) {
+				t.Fatal("expected trackID to stay the same when only the path changes but MBID doesn't")
+			}
+			if mapper.trackID(before) != "mbid-1" {
+				t.Fatalf("expected trackID to be the MBID, got %q", mapper.trackID(before))
+			}
+		})
+	})
+
+	t.Run("MBID survives unrelated tag edits", func(t *testing.T) {
+		withStableIDs(t, true, func() {
+			before := fakeMetadata{filePath: "/music/a/song.mp3", mbzTrackID: "mbid-1", title: "Old Title"}
+			after := fakeMetadata{filePath: "/music/a/song.mp3", mbzTrackID: "mbid-1", title: "Fixed Title"}
+			if mapper.trackID(before) != mapper.trackID(after) {
+				t.Fatal("expected trackID to be unaffected by a tag edit when MBID is unchanged")
+			}
+		})
+	})
+
+	t.Run("falls back to hash when MBID is missing even with StableIDs on", func(t *testing.T) {
+		withStableIDs(t, true, func() {
+			md := fakeMetadata{filePath: "/music/a/song.mp3"}
+			if mapper.trackID(md) == "" {
+				t.Fatal("expected a non-empty fallback trackID")
+			}
+			if mapper.trackID(md) == "mbid-1" {
+				t.Fatal("did not expect an MBID-shaped id when no MBID tag is present")
+			}
+		})
+	})
+}
+
+func TestArtistIDStability(t *testing.T) {
+	mapper := newMediaFileMapper("/music")
+
+	withStableIDs(t, true, func() {
+		before := fakeMetadata{artist: "Artist Name", mbzArtistID: "artist-mbid"}
+		renamed := fakeMetadata{artist: "Artist Name (typo fixed)", mbzArtistID: "artist-mbid"}
+		if mapper.artistID(before) != mapper.artistID(renamed) {
+			t.Fatal("expected artistID to stay stable across a name edit when MBID is unchanged")
+		}
+	})
+
+	withStableIDs(t, false, func() {
+		a := fakeMetadata{artist: "Artist Name"}
+		b := fakeMetadata{artist: "artist name"}
+		if mapper.artistID(a) != mapper.artistID(b) {
+			t.Fatal("expected the hash fallback to still be case-insensitive, as before")
+		}
+	})
+}
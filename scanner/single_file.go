@@ -0,0 +1,32 @@
+package scanner
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/deluan/navidrome/model"
+	"github.com/deluan/navidrome/scanner/metadata"
+)
+
+// extensions recognized as media files worth indexing. Kept in sync with the set the
+// regular folder scan accepts.
+var extensions = map[string]bool{
+	".mp3": true, ".flac": true, ".ogg": true, ".m4a": true, ".m4b": true,
+	".wma": true, ".wav": true, ".aac": true, ".ape": true, ".opus": true,
+}
+
+// MapSingleFile reads the tags of a single file under rootFolder and maps it to a
+// model.MediaFile, for callers (like the filesystem watcher) that need to index one file
+// at a time instead of walking the whole folder. ok is false when path isn't a recognized
+// media file, in which case mf is the zero value.
+func MapSingleFile(rootFolder, path string) (mf model.MediaFile, ok bool, err error) {
+	if !extensions[strings.ToLower(filepath.Ext(path))] {
+		return mf, false, nil
+	}
+	md, err := metadata.Extract(path)
+	if err != nil {
+		return mf, false, err
+	}
+	mapper := newMediaFileMapper(rootFolder)
+	return mapper.toMediaFile(md), true, nil
+}
@@ -0,0 +1,20 @@
+// +build !windows
+
+package watcher
+
+import (
+	"os"
+	"syscall"
+)
+
+// inode returns the inode number backing info, when the OS exposes one. A rename/move
+// within the same filesystem keeps the same inode, which is what lets claimAsMove tell a
+// moved file apart from an unrelated file that merely happens to share its size and
+// modification time (e.g. two tracks from the same batch rip).
+func inode(info os.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return stat.Ino, true
+}
@@ -0,0 +1,11 @@
+// +build windows
+
+package watcher
+
+import "os"
+
+// inode is not available through os.FileInfo on Windows; callers fall back to matching on
+// size and modification time alone.
+func inode(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,297 @@
+// Package watcher implements an incremental alternative to the periodic full rescan: it
+// watches conf.Server.MusicFolder for filesystem changes and applies just the affected
+// files instead of re-reading the whole library.
+package watcher
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/deluan/navidrome/log"
+	"github.com/deluan/navidrome/model"
+	"github.com/deluan/navidrome/scanner"
+	"github.com/fsnotify/fsnotify"
+)
+
+// debounce is the quiet window applied per directory before a batch of events is
+// processed, so that a burst of writes (e.g. a tag editor re-saving a whole album) only
+// triggers a single pass over that directory.
+const debounce = 2 * time.Second
+
+// Scanner is the subset of the regular scanner used as a fallback when a subtree can't be
+// watched directly, e.g. because the inotify watch limit was reached.
+type Scanner interface {
+	RescanAll(full bool) error
+}
+
+// Watcher watches rootFolder recursively and applies incremental updates to ds as files
+// are created, written, moved or removed.
+type Watcher struct {
+	rootFolder string
+	ds         model.DataStore
+	fallback   Scanner
+
+	mu      sync.Mutex
+	pending map[string]*time.Timer // directory -> debounce timer
+	known   map[string]fileStat    // path -> stat, as of the last time it was seen present
+	recent  []removedFile          // recently deleted files, for move detection
+}
+
+// fileStat is the slice of os.FileInfo that matters for move detection.
+type fileStat struct {
+	size    int64
+	modTime time.Time
+	ino     uint64
+	hasIno  bool
+}
+
+func statOf(info os.FileInfo) fileStat {
+	ino, ok := inode(info)
+	return fileStat{size: info.Size(), modTime: info.ModTime(), ino: ino, hasIno: ok}
+}
+
+// sameFile reports whether two stats likely refer to the same underlying file content.
+// Inode equality is authoritative when available -- a rename/move within the same
+// filesystem preserves it, so two different files that merely share a size and
+// modification time (e.g. two tracks from the same batch rip) won't be confused for a
+// move. Without an inode (e.g. on Windows, or a cross-filesystem move), size+modTime is
+// the best available signal.
+func (s fileStat) sameFile(other fileStat) bool {
+	if s.hasIno && other.hasIno {
+		return s.ino == other.ino
+	}
+	return s.size == other.size && s.modTime.Equal(other.modTime)
+}
+
+// removedFile is kept around for a short window after a delete event so a subsequent
+// create matching its stat can be recognized as a move/rename rather than a new file.
+type removedFile struct {
+	path   string
+	stat   fileStat
+	seenAt time.Time
+}
+
+const moveWindow = 5 * time.Second
+
+func New(rootFolder string, ds model.DataStore, fallback Scanner) *Watcher {
+	return &Watcher{
+		rootFolder: rootFolder,
+		ds:         ds,
+		fallback:   fallback,
+		pending:    map[string]*time.Timer{},
+		known:      map[string]fileStat{},
+	}
+}
+
+// Run starts the watcher and blocks until it is stopped or fsnotify reports a fatal error.
+func (w *Watcher) Run() error {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer fsw.Close()
+
+	if err := w.addRecursive(fsw, w.rootFolder); err != nil {
+		return err
+	}
+	log.Info("Watching music folder for changes", "folder", w.rootFolder)
+
+	for {
+		select {
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return nil
+			}
+			w.handleEvent(fsw, event)
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return nil
+			}
+			log.Error("Watcher error", err)
+		}
+	}
+}
+
+// addRecursive adds dir and all its subdirectories to fsw. If the OS watch limit is hit
+// (ENOSPC on Linux, surfaced as a generic error by fsnotify), the offending subtree is
+// logged, left unwatched, and covered by a periodic rescan instead (see
+// schedulePollingFallback).
+func (w *Watcher) addRecursive(fsw *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if err := fsw.Add(path); err != nil {
+			log.Error("Could not watch folder, falling back to periodic scans for it", "folder", path, err)
+			w.schedulePollingFallback(path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+// schedulePollingFallback triggers a rescan of the whole library every 5 minutes, to cover
+// a subtree that could not be watched directly (e.g. inotify watch-limit exhaustion). The
+// rescan is RescanAll(false), not a full one: folders unchanged since the last scan are
+// skipped.
+func (w *Watcher) schedulePollingFallback(dir string) {
+	if w.fallback == nil {
+		return
+	}
+	go func() {
+		t := time.NewTicker(5 * time.Minute)
+		defer t.Stop()
+		for range t.C {
+			if err := w.fallback.RescanAll(false); err != nil {
+				log.Error("Fallback rescan failed", "folder", dir, err)
+			}
+		}
+	}()
+}
+
+func (w *Watcher) handleEvent(fsw *fsnotify.Watcher, event fsnotify.Event) {
+	if info, err := os.Stat(event.Name); err == nil && info.IsDir() && event.Op&fsnotify.Create != 0 {
+		_ = w.addRecursive(fsw, event.Name)
+	}
+
+	dir := filepath.Dir(event.Name)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if t, ok := w.pending[dir]; ok {
+		t.Stop()
+	}
+	w.pending[dir] = time.AfterFunc(debounce, func() {
+		w.processDir(dir)
+	})
+}
+
+// processDir re-evaluates every regular file currently in dir, applying creates/writes
+// through mediaFileMapper.toMediaFile + MediaFileRepository.Put, and reconciles the ones
+// that disappeared as deletes (or moves, if a matching recently-removed file is found).
+func (w *Watcher) processDir(dir string) {
+	ctx := context.Background()
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		log.Error("Could not read watched folder", "folder", dir, err)
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		seen[path] = true
+		w.rememberSeen(path, statOf(e))
+		if err := w.applyFile(ctx, path); err != nil {
+			log.Error("Could not index changed file", "path", path, err)
+		}
+	}
+
+	w.reconcileDeletes(ctx, dir, seen)
+}
+
+func (w *Watcher) applyFile(ctx context.Context, path string) error {
+	mf, ok, err := scanner.MapSingleFile(w.rootFolder, path)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil // not a media file we recognize (e.g. playlist, artwork, non-audio)
+	}
+	if moved := w.claimAsMove(path); moved != "" {
+		log.Debug(ctx, "Detected moved file", "from", moved, "to", path)
+	}
+	return w.ds.MediaFile(ctx).Put(&mf)
+}
+
+// reconcileDeletes removes media files under dir that are no longer present on disk, then
+// asks the datastore to garbage-collect any album/artist left without tracks.
+func (w *Watcher) reconcileDeletes(ctx context.Context, dir string, stillPresent map[string]bool) {
+	repo := w.ds.MediaFile(ctx)
+	all, err := repo.FindByPath(dir)
+	if err != nil {
+		log.Error("Could not list indexed files for watched folder", "folder", dir, err)
+		return
+	}
+	var removed bool
+	for _, mf := range all {
+		if stillPresent[mf.Path] {
+			continue
+		}
+		// The file is already gone from disk by the time we notice, so its stat has to
+		// come from the last time processDir saw it present, not from stat'ing it now.
+		if st, ok := w.takeKnown(mf.Path); ok {
+			w.rememberRemoved(mf.Path, st)
+		}
+		if err := repo.Delete(mf.ID); err != nil {
+			log.Error("Could not remove deleted file from index", "path", mf.Path, err)
+			continue
+		}
+		removed = true
+	}
+	if removed {
+		if err := w.ds.GC(ctx, w.rootFolder); err != nil {
+			log.Error("Could not purge orphan albums/artists", err)
+		}
+	}
+}
+
+func (w *Watcher) rememberSeen(path string, st fileStat) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.known[path] = st
+}
+
+func (w *Watcher) takeKnown(path string) (fileStat, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	st, ok := w.known[path]
+	delete(w.known, path)
+	return st, ok
+}
+
+func (w *Watcher) rememberRemoved(path string, st fileStat) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := time.Now()
+	var fresh []removedFile
+	for _, r := range w.recent {
+		if now.Sub(r.seenAt) < moveWindow {
+			fresh = append(fresh, r)
+		}
+	}
+	w.recent = append(fresh, removedFile{path: path, stat: st, seenAt: now})
+}
+
+// claimAsMove looks for a recently-removed file whose stat matches newPath's (preferring
+// inode equality over size+modTime, see fileStat.sameFile), consuming it if found. It
+// returns the old path so callers can log the rename. It does not move annotations: they
+// stay attached only when the moved file gets the same media file ID, which is the case
+// when the ID comes from its MusicBrainz track ID (conf.Server.StableIDs). With the
+// path-hash ID the moved file gets a new ID and the old file's annotations are not carried
+// over to it.
+func (w *Watcher) claimAsMove(newPath string) string {
+	info, err := os.Stat(newPath)
+	if err != nil {
+		return ""
+	}
+	st := statOf(info)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, r := range w.recent {
+		if r.stat.sameFile(st) {
+			w.recent = append(w.recent[:i], w.recent[i+1:]...)
+			return r.path
+		}
+	}
+	return ""
+}
@@ -0,0 +1,139 @@
+package watcher
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestFileStatSameFile(t *testing.T) {
+	t.Run("inode equality wins even if size/modTime differ", func(t *testing.T) {
+		a := fileStat{size: 100, modTime: time.Unix(1, 0), ino: 42, hasIno: true}
+		b := fileStat{size: 200, modTime: time.Unix(2, 0), ino: 42, hasIno: true}
+		if !a.sameFile(b) {
+			t.Fatal("expected matching inodes to be treated as the same file")
+		}
+	})
+
+	t.Run("differing inodes are never the same file, regardless of size/modTime", func(t *testing.T) {
+		a := fileStat{size: 100, modTime: time.Unix(1, 0), ino: 1, hasIno: true}
+		b := fileStat{size: 100, modTime: time.Unix(1, 0), ino: 2, hasIno: true}
+		if a.sameFile(b) {
+			t.Fatal("expected different inodes to never match, even with identical size/modTime")
+		}
+	})
+
+	t.Run("falls back to size+modTime when inode info is unavailable", func(t *testing.T) {
+		a := fileStat{size: 100, modTime: time.Unix(1, 0)}
+		b := fileStat{size: 100, modTime: time.Unix(1, 0)}
+		if !a.sameFile(b) {
+			t.Fatal("expected size+modTime fallback to match identical stats")
+		}
+		c := fileStat{size: 101, modTime: time.Unix(1, 0)}
+		if a.sameFile(c) {
+			t.Fatal("expected size+modTime fallback to reject a differing size")
+		}
+	})
+}
+
+func TestClaimAsMove(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.mp3")
+	newPath := filepath.Join(dir, "new.mp3")
+	if err := ioutil.WriteFile(newPath, []byte("same content, different name"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := New(dir, nil, nil)
+
+	t.Run("matches a recently removed file with the same stat", func(t *testing.T) {
+		w.rememberRemoved(oldPath, statOf(info))
+		moved := w.claimAsMove(newPath)
+		if moved != oldPath {
+			t.Fatalf("expected claimAsMove to report %q as the old path, got %q", oldPath, moved)
+		}
+	})
+
+	t.Run("a claimed move is consumed and won't match again", func(t *testing.T) {
+		if moved := w.claimAsMove(newPath); moved != "" {
+			t.Fatalf("expected the removedFile entry to be consumed after the first claim, got %q", moved)
+		}
+	})
+
+	t.Run("no match when nothing was removed", func(t *testing.T) {
+		if moved := w.claimAsMove(newPath); moved != "" {
+			t.Fatalf("expected no match with an empty recent list, got %q", moved)
+		}
+	})
+}
+
+func TestRememberRemovedExpiresStaleEntries(t *testing.T) {
+	w := New(t.TempDir(), nil, nil)
+	stale := fileStat{size: 1, modTime: time.Unix(1, 0)}
+	w.mu.Lock()
+	w.recent = append(w.recent, removedFile{path: "stale.mp3", stat: stale, seenAt: time.Now().Add(-2 * moveWindow)})
+	w.mu.Unlock()
+
+	w.rememberRemoved("fresh.mp3", fileStat{size: 2, modTime: time.Unix(2, 0)})
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, r := range w.recent {
+		if r.path == "stale.mp3" {
+			t.Fatal("expected an entry older than moveWindow to be dropped")
+		}
+	}
+	if len(w.recent) != 1 {
+		t.Fatalf("expected only the fresh entry to remain, got %d entries", len(w.recent))
+	}
+}
+
+func TestKnownSeenRoundTrip(t *testing.T) {
+	w := New(t.TempDir(), nil, nil)
+	st := fileStat{size: 10, modTime: time.Unix(5, 0)}
+	w.rememberSeen("a.mp3", st)
+
+	got, ok := w.takeKnown("a.mp3")
+	if !ok || got != st {
+		t.Fatalf("expected takeKnown to return the remembered stat, got %+v, %v", got, ok)
+	}
+	if _, ok := w.takeKnown("a.mp3"); ok {
+		t.Fatal("expected takeKnown to remove the entry after reading it")
+	}
+}
+
+func TestHandleEventDebouncesPerDirectory(t *testing.T) {
+	w := New(t.TempDir(), nil, nil)
+	dir := filepath.Join(w.rootFolder, "album")
+	path := filepath.Join(dir, "track.mp3")
+
+	w.handleEvent(nil, fsnotify.Event{Name: path, Op: fsnotify.Write})
+	w.mu.Lock()
+	first, ok := w.pending[dir]
+	w.mu.Unlock()
+	if !ok {
+		t.Fatal("expected a pending debounce timer to be scheduled for the directory")
+	}
+
+	w.handleEvent(nil, fsnotify.Event{Name: path, Op: fsnotify.Write})
+	w.mu.Lock()
+	second := w.pending[dir]
+	count := len(w.pending)
+	w.mu.Unlock()
+
+	if count != 1 {
+		t.Fatalf("expected a second event in the same directory to coalesce into one pending timer, got %d", count)
+	}
+	if first == second {
+		t.Fatal("expected the second event to replace the timer, not reuse the same one")
+	}
+	second.Stop()
+}